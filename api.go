@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	elasticsearch "github.com/elastic/go-elasticsearch/v7"
+)
+
+// apiServer exposes the scraper's search index and feed list over HTTP,
+// sharing the DB/ES connections and feed registry with the background
+// scraping loop. Mutating endpoints require a JWT bearer token.
+type apiServer struct {
+	db        *sql.DB
+	esClient  *elasticsearch.Client
+	feeds     *FeedRegistry
+	jwtSecret []byte
+	trigger   func()
+}
+
+func newAPIServer(db *sql.DB, esClient *elasticsearch.Client, feeds *FeedRegistry, jwtSecret []byte, trigger func()) *apiServer {
+	return &apiServer{db: db, esClient: esClient, feeds: feeds, jwtSecret: jwtSecret, trigger: trigger}
+}
+
+// startAPIServer builds the apiServer and starts it listening in the
+// background, returning the *http.Server so the caller can shut it down.
+func startAPIServer(db *sql.DB, esClient *elasticsearch.Client, feeds *FeedRegistry, jwtSecret string, trigger func()) *http.Server {
+	api := newAPIServer(db, esClient, feeds, []byte(jwtSecret), trigger)
+
+	addr := os.Getenv("API_ADDR")
+	if addr == "" {
+		addr = ":8080"
+	}
+
+	srv := &http.Server{Addr: addr, Handler: api.routes()}
+	go func() {
+		log.Println("API listening on", addr)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Println(err)
+		}
+	}()
+
+	return srv
+}
+
+func (s *apiServer) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/search", s.handleSearch)
+	mux.HandleFunc("/sites", s.handleSites)
+	mux.HandleFunc("/feeds", s.requireJWT(s.handleFeeds))
+	mux.HandleFunc("/feeds/", s.requireJWT(s.handleFeedByID))
+	mux.HandleFunc("/scrape/trigger", s.requireJWT(s.handleTrigger))
+	return mux
+}
+
+type siteRow struct {
+	Id          string `json:"id"`
+	FeedUrl     string `json:"feedUrl"`
+	Title       string `json:"title"`
+	Link        string `json:"link"`
+	Description string `json:"description"`
+}
+
+func (s *apiServer) handleSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query().Get("q")
+	if q == "" {
+		http.Error(w, "q is required", http.StatusBadRequest)
+		return
+	}
+
+	page := parsePositiveInt(r.URL.Query().Get("page"), 1)
+	size := parsePositiveInt(r.URL.Query().Get("size"), 20)
+
+	query, err := json.Marshal(map[string]interface{}{
+		"from": (page - 1) * size,
+		"size": size,
+		"query": map[string]interface{}{
+			"multi_match": map[string]interface{}{
+				"query":  q,
+				"fields": []string{"title", "description"},
+			},
+		},
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res, err := s.esClient.Search(
+		s.esClient.Search.WithContext(r.Context()),
+		s.esClient.Search.WithIndex("articles"),
+		s.esClient.Search.WithBody(bytes.NewReader(query)),
+	)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer res.Body.Close()
+
+	w.Header().Set("Content-Type", "application/json")
+	if res.IsError() {
+		w.WriteHeader(res.StatusCode)
+	}
+	io.Copy(w, res.Body)
+}
+
+func (s *apiServer) handleSites(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rows, err := s.db.QueryContext(r.Context(), "SELECT id, feedurl, title, link, description FROM Sites")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	sites := []siteRow{}
+	for rows.Next() {
+		var site siteRow
+		if err := rows.Scan(&site.Id, &site.FeedUrl, &site.Title, &site.Link, &site.Description); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		sites = append(sites, site)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sites)
+}
+
+func (s *apiServer) handleFeeds(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body struct {
+		URL string `json:"url"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "invalid body", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.feeds.Add(r.Context(), body.URL); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{
+		"id":  base64.URLEncoding.EncodeToString([]byte(body.URL)),
+		"url": body.URL,
+	})
+}
+
+func (s *apiServer) handleFeedByID(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/feeds/")
+	feedUrl, err := base64.URLEncoding.DecodeString(id)
+	if err != nil {
+		http.Error(w, "invalid feed id", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.feeds.Remove(r.Context(), string(feedUrl)); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *apiServer) handleTrigger(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	go s.trigger()
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func parsePositiveInt(raw string, fallback int) int {
+	if raw == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n <= 0 {
+		return fallback
+	}
+	return n
+}
+
+// requireJWT rejects requests that don't carry a valid HS256 bearer token
+// signed with the server's JWT secret before calling next.
+func (s *apiServer) requireJWT(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		authHeader := r.Header.Get("Authorization")
+		token := strings.TrimPrefix(authHeader, "Bearer ")
+		if token == "" || token == authHeader {
+			http.Error(w, "missing bearer token", http.StatusUnauthorized)
+			return
+		}
+
+		if err := verifyHS256JWT(token, s.jwtSecret); err != nil {
+			http.Error(w, "invalid token: "+err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// verifyHS256JWT checks the header's alg and the signature of an HS256 JWT
+// against secret, and validates the standard exp claim. A token with no
+// exp claim is rejected rather than treated as non-expiring.
+func verifyHS256JWT(token string, secret []byte) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("malformed token")
+	}
+
+	header, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return fmt.Errorf("malformed header")
+	}
+
+	var head struct {
+		Alg string `json:"alg"`
+	}
+	if err := json.Unmarshal(header, &head); err != nil {
+		return fmt.Errorf("malformed header")
+	}
+	if head.Alg != "HS256" {
+		return fmt.Errorf("unsupported alg %q", head.Alg)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return fmt.Errorf("malformed signature")
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(mac.Sum(nil), sig) {
+		return fmt.Errorf("signature mismatch")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return fmt.Errorf("malformed payload")
+	}
+
+	var claims struct {
+		Exp *int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return fmt.Errorf("malformed claims")
+	}
+
+	if claims.Exp == nil {
+		return fmt.Errorf("token has no exp claim")
+	}
+	if time.Now().Unix() > *claims.Exp {
+		return fmt.Errorf("token expired")
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func signHS256(t *testing.T, header, payload map[string]interface{}, secret []byte) string {
+	t.Helper()
+
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(payloadJSON)
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signingInput))
+	sig := base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+
+	return signingInput + "." + sig
+}
+
+func TestVerifyHS256JWT(t *testing.T) {
+	secret := []byte("test-secret")
+
+	valid := signHS256(t, map[string]interface{}{"alg": "HS256", "typ": "JWT"}, map[string]interface{}{"exp": time.Now().Add(time.Hour).Unix()}, secret)
+	if err := verifyHS256JWT(valid, secret); err != nil {
+		t.Errorf("expected a valid token to verify, got: %v", err)
+	}
+
+	expired := signHS256(t, map[string]interface{}{"alg": "HS256"}, map[string]interface{}{"exp": time.Now().Add(-time.Hour).Unix()}, secret)
+	if err := verifyHS256JWT(expired, secret); err == nil {
+		t.Error("expected an expired token to fail verification")
+	}
+
+	noExp := signHS256(t, map[string]interface{}{"alg": "HS256"}, map[string]interface{}{}, secret)
+	if err := verifyHS256JWT(noExp, secret); err == nil {
+		t.Error("expected a token without an exp claim to fail verification")
+	}
+
+	wrongAlg := signHS256(t, map[string]interface{}{"alg": "none"}, map[string]interface{}{"exp": time.Now().Add(time.Hour).Unix()}, secret)
+	if err := verifyHS256JWT(wrongAlg, secret); err == nil {
+		t.Error("expected a non-HS256 alg to fail verification")
+	}
+
+	wrongSecret := signHS256(t, map[string]interface{}{"alg": "HS256"}, map[string]interface{}{"exp": time.Now().Add(time.Hour).Unix()}, []byte("other-secret"))
+	if err := verifyHS256JWT(wrongSecret, secret); err == nil {
+		t.Error("expected a token signed with a different secret to fail verification")
+	}
+
+	if err := verifyHS256JWT("not-a-jwt", secret); err == nil {
+		t.Error("expected a malformed token to fail verification")
+	}
+}
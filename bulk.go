@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	elasticsearch "github.com/elastic/go-elasticsearch/v7"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+)
+
+const (
+	bulkMaxItems       = 500
+	bulkMaxBytes       = 5 * 1024 * 1024
+	bulkInitialBackoff = 100 * time.Millisecond
+	bulkMaxBackoff     = 30 * time.Second
+)
+
+type bulkAction struct {
+	index string
+	id    string
+}
+
+// BulkIndexer buffers ES index actions and flushes them to the _bulk
+// endpoint as NDJSON, so a cycle's worth of articles/sites can be written
+// in a handful of requests instead of one Update (plus a 404 fallback
+// Create) per document. Flushing retries transient failures (429/503,
+// network errors) with exponential backoff and jitter.
+type BulkIndexer struct {
+	client *elasticsearch.Client
+
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	actions []bulkAction
+}
+
+func NewBulkIndexer(client *elasticsearch.Client) *BulkIndexer {
+	return &BulkIndexer{client: client}
+}
+
+// Add buffers an upsert-by-id "index" action for doc under index/id, and
+// flushes immediately if the size-based triggers are hit.
+func (b *BulkIndexer) Add(ctx context.Context, index, id string, doc interface{}) error {
+	docData, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	meta, err := json.Marshal(map[string]map[string]string{
+		"index": {"_index": index, "_id": id},
+	})
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	b.buf.Write(meta)
+	b.buf.WriteByte('\n')
+	b.buf.Write(docData)
+	b.buf.WriteByte('\n')
+	b.actions = append(b.actions, bulkAction{index: index, id: id})
+	shouldFlush := len(b.actions) >= bulkMaxItems || b.buf.Len() >= bulkMaxBytes
+	b.mu.Unlock()
+
+	if shouldFlush {
+		return b.Flush(ctx)
+	}
+	return nil
+}
+
+// Flush sends whatever is currently buffered to the ES _bulk endpoint. It
+// retries the whole batch on transient failures (429/503/network errors)
+// with exponential backoff up to bulkMaxBackoff, and surfaces only the
+// permanent per-item failures reported in the response. It gives up early
+// if ctx is cancelled.
+func (b *BulkIndexer) Flush(ctx context.Context) error {
+	b.mu.Lock()
+	if len(b.actions) == 0 {
+		b.mu.Unlock()
+		return nil
+	}
+	body := make([]byte, b.buf.Len())
+	copy(body, b.buf.Bytes())
+	actions := b.actions
+	b.buf.Reset()
+	b.actions = nil
+	b.mu.Unlock()
+
+	res, err := bulkRequestWithRetry(ctx, func() (*esapi.Response, error) {
+		return b.client.Bulk(bytes.NewReader(body), b.client.Bulk.WithContext(ctx))
+	})
+	if err != nil {
+		return fmt.Errorf("bulk flush: %w", err)
+	}
+	defer res.Body.Close()
+
+	return parseBulkResponse(res.Body, actions)
+}
+
+func isRetryableStatus(status int) bool {
+	return status == 429 || status == 503
+}
+
+// bulkRequestWithRetry calls send, retrying on transient failures
+// (429/503/network errors) with exponential backoff and jitter up to
+// bulkMaxBackoff. It's shared by BulkIndexer.Flush and the cleanup job's
+// ES bulk deletes.
+func bulkRequestWithRetry(ctx context.Context, send func() (*esapi.Response, error)) (*esapi.Response, error) {
+	backoff := bulkInitialBackoff
+	for {
+		res, err := send()
+		if err == nil && !isRetryableStatus(res.StatusCode) {
+			return res, nil
+		}
+
+		if res != nil {
+			res.Body.Close()
+		}
+
+		if backoff > bulkMaxBackoff {
+			if err != nil {
+				return nil, fmt.Errorf("giving up after retries: %w", err)
+			}
+			return nil, fmt.Errorf("giving up after retries: status %d", res.StatusCode)
+		}
+
+		log.Printf("> Bulk request failed, retrying in %s: %v", backoff, err)
+		select {
+		case <-time.After(backoff + time.Duration(rand.Int63n(int64(backoff)/2+1))):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+// parseBulkResponse walks the per-item results of a _bulk response and
+// logs the documents that permanently failed to index. It does not return
+// an error for those, since the rest of the batch already succeeded.
+func parseBulkResponse(body io.Reader, actions []bulkAction) error {
+	var parsed struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Status int `json:"status"`
+			Error  struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			} `json:"error"`
+		} `json:"items"`
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return err
+	}
+
+	if !parsed.Errors {
+		return nil
+	}
+
+	var failed []string
+	for i, item := range parsed.Items {
+		for _, result := range item {
+			if result.Status >= 300 && i < len(actions) {
+				failed = append(failed, fmt.Sprintf("%s/%s: %d %s", actions[i].index, actions[i].id, result.Status, result.Error.Reason))
+			}
+		}
+	}
+
+	if len(failed) > 0 {
+		log.Printf("> Bulk flush: %d permanent failures: %s", len(failed), strings.Join(failed, "; "))
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseBulkResponseNoErrors(t *testing.T) {
+	body := strings.NewReader(`{"errors":false,"items":[{"index":{"status":201}}]}`)
+	if err := parseBulkResponse(body, []bulkAction{{index: "articles", id: "a1"}}); err != nil {
+		t.Errorf("expected no error, got: %v", err)
+	}
+}
+
+func TestParseBulkResponseWithFailures(t *testing.T) {
+	body := strings.NewReader(`{
+		"errors": true,
+		"items": [
+			{"index": {"status": 201}},
+			{"index": {"status": 409, "error": {"type": "version_conflict", "reason": "conflict"}}}
+		]
+	}`)
+	actions := []bulkAction{
+		{index: "articles", id: "a1"},
+		{index: "articles", id: "a2"},
+	}
+	if err := parseBulkResponse(body, actions); err != nil {
+		t.Errorf("expected per-item failures to be logged, not returned as an error: %v", err)
+	}
+}
+
+func TestParseBulkDeleteResponse(t *testing.T) {
+	body := strings.NewReader(`{
+		"items": [
+			{"delete": {"status": 200}},
+			{"delete": {"status": 404}},
+			{"delete": {"status": 200}}
+		]
+	}`)
+	succeeded, err := parseBulkDeleteResponse(body)
+	if err != nil {
+		t.Fatalf("parseBulkDeleteResponse: %v", err)
+	}
+	if succeeded != 2 {
+		t.Errorf("got %d succeeded deletes, want 2", succeeded)
+	}
+}
+
+func TestParseBulkDeleteResponseMalformed(t *testing.T) {
+	body := strings.NewReader(`not json`)
+	if _, err := parseBulkDeleteResponse(body); err == nil {
+		t.Error("expected an error for malformed JSON")
+	}
+}
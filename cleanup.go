@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+
+	elasticsearch "github.com/elastic/go-elasticsearch/v7"
+	"github.com/elastic/go-elasticsearch/v7/esapi"
+)
+
+const (
+	// cleanupBatchLimit caps how many rows a single cleanup pass deletes,
+	// so a very large backlog can't monopolize a cycle.
+	cleanupBatchLimit = 50_000
+	esDeleteBatchSize = 1000
+)
+
+// cleanOutdated pushes the age filter into Postgres itself, deleting
+// outdated rows in one statement and streaming back only the ids that
+// also need removing from ES, instead of loading every article into
+// memory and issuing a DELETE/ES-delete pair per row.
+func cleanOutdated(ctx context.Context, db *sql.DB, esClient *elasticsearch.Client) error {
+	log.Println("Cleanup...")
+
+	rows, err := db.QueryContext(
+		ctx,
+		`DELETE FROM Articles
+     WHERE id IN (
+       SELECT id FROM Articles WHERE pubdate < NOW() - $1::interval LIMIT $2
+     )
+     RETURNING id`,
+		fmt.Sprintf("%d seconds", int(articleThreshold.Seconds())),
+		cleanupBatchLimit,
+	)
+	if err != nil {
+		return fmt.Errorf("cleanup delete: %w", err)
+	}
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("cleanup scan: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return fmt.Errorf("cleanup rows: %w", err)
+	}
+	rows.Close()
+
+	deleted := bulkDeleteFromES(ctx, esClient, ids)
+
+	log.Printf("> Done. %d rows deleted from Postgres. %d deleted from ES", len(ids), deleted)
+	return nil
+}
+
+// bulkDeleteFromES removes ids from the articles index in batches of
+// esDeleteBatchSize, retrying transient failures with backoff. A batch
+// that still fails afterwards is logged and skipped rather than aborting
+// the rest of the cleanup.
+func bulkDeleteFromES(ctx context.Context, esClient *elasticsearch.Client, ids []string) int {
+	deleted := 0
+
+	for i := 0; i < len(ids); i += esDeleteBatchSize {
+		end := i + esDeleteBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		batch := ids[i:end]
+
+		var buf bytes.Buffer
+		for _, id := range batch {
+			meta, err := json.Marshal(map[string]map[string]string{
+				"delete": {"_index": "articles", "_id": id},
+			})
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			buf.Write(meta)
+			buf.WriteByte('\n')
+		}
+
+		res, err := bulkRequestWithRetry(ctx, func() (*esapi.Response, error) {
+			return esClient.Bulk(bytes.NewReader(buf.Bytes()), esClient.Bulk.WithContext(ctx))
+		})
+		if err != nil {
+			log.Printf("> ES bulk delete failed for %d ids: %v", len(batch), err)
+			continue
+		}
+
+		succeeded, parseErr := parseBulkDeleteResponse(res.Body)
+		res.Body.Close()
+
+		if res.IsError() {
+			log.Printf("> ES bulk delete error: %s", res.Status())
+		}
+		if parseErr != nil {
+			log.Println(parseErr)
+			continue
+		}
+
+		deleted += succeeded
+	}
+
+	return deleted
+}
+
+// parseBulkDeleteResponse counts the items in a _bulk response that were
+// actually deleted, so a partially-failed batch doesn't get reported as
+// fully cleaned up.
+func parseBulkDeleteResponse(body io.Reader) (int, error) {
+	var parsed struct {
+		Items []map[string]struct {
+			Status int `json:"status"`
+		} `json:"items"`
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return 0, err
+	}
+
+	succeeded := 0
+	for _, item := range parsed.Items {
+		for _, result := range item {
+			if result.Status < 300 {
+				succeeded++
+			}
+		}
+	}
+
+	return succeeded, nil
+}
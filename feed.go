@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Item is a single normalized feed entry, regardless of which of the
+// supported formats (RSS 2.0, RSS 1.0/RDF, Atom 1.0) it came from.
+type Item struct {
+	Title       string
+	Link        string
+	Description string
+	Content     string
+	Author      string
+	Categories  []string
+	GUID        string
+	PubDate     time.Time
+}
+
+// Feed is a normalized feed, regardless of which of the supported formats
+// it was parsed from.
+type Feed struct {
+	Title       string
+	Link        string
+	Description string
+	Items       []Item
+}
+
+// parseFeed sniffs body's root XML element and dispatches to the parser
+// for the matching format.
+func parseFeed(body []byte) (Feed, error) {
+	root, err := rootElementName(body)
+	if err != nil {
+		return Feed{}, err
+	}
+
+	switch strings.ToLower(root) {
+	case "rss":
+		return parseRSS2(body)
+	case "rdf":
+		return parseRDF(body)
+	case "feed":
+		return parseAtom(body)
+	default:
+		return Feed{}, fmt.Errorf("unrecognized feed format (root element %q)", root)
+	}
+}
+
+func rootElementName(body []byte) (string, error) {
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return "", fmt.Errorf("sniffing feed root element: %w", err)
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return start.Name.Local, nil
+		}
+	}
+}
+
+// rss2Doc mirrors RSS 2.0's <rss><channel><item> shape, plus the
+// content:encoded and dc:creator extensions commonly carried alongside it.
+type rss2Doc struct {
+	XMLName xml.Name `xml:"rss"`
+	Channel struct {
+		Title       string `xml:"title"`
+		Link        string `xml:"link"`
+		Description string `xml:"description"`
+		Items       []struct {
+			Title          string   `xml:"title"`
+			Link           string   `xml:"link"`
+			Description    string   `xml:"description"`
+			PubDate        string   `xml:"pubDate"`
+			Author         string   `xml:"author"`
+			Categories     []string `xml:"category"`
+			GUID           string   `xml:"guid"`
+			ContentEncoded string   `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+			DCCreator      string   `xml:"http://purl.org/dc/elements/1.1/ creator"`
+		} `xml:"item"`
+	} `xml:"channel"`
+}
+
+func parseRSS2(body []byte) (Feed, error) {
+	var doc rss2Doc
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		return Feed{}, fmt.Errorf("parsing RSS 2.0 feed: %w", err)
+	}
+
+	feed := Feed{
+		Title:       doc.Channel.Title,
+		Link:        doc.Channel.Link,
+		Description: doc.Channel.Description,
+	}
+
+	for _, it := range doc.Channel.Items {
+		feed.Items = append(feed.Items, Item{
+			Title:       it.Title,
+			Link:        it.Link,
+			Description: it.Description,
+			Content:     it.ContentEncoded,
+			Author:      firstNonEmpty(it.Author, it.DCCreator),
+			Categories:  it.Categories,
+			GUID:        firstNonEmpty(it.GUID, it.Link),
+			PubDate:     parseFeedDate(it.PubDate),
+		})
+	}
+
+	return feed, nil
+}
+
+// rdfDoc mirrors RSS 1.0 / RDF's <rdf:RDF><channel>, <item>* shape, where
+// items are siblings of channel rather than nested inside it.
+type rdfDoc struct {
+	XMLName xml.Name `xml:"RDF"`
+	Channel struct {
+		Title       string `xml:"title"`
+		Link        string `xml:"link"`
+		Description string `xml:"description"`
+	} `xml:"channel"`
+	Items []struct {
+		About       string `xml:"about,attr"`
+		Title       string `xml:"title"`
+		Link        string `xml:"link"`
+		Description string `xml:"description"`
+		Date        string `xml:"http://purl.org/dc/elements/1.1/ date"`
+		Creator     string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	} `xml:"item"`
+}
+
+func parseRDF(body []byte) (Feed, error) {
+	var doc rdfDoc
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		return Feed{}, fmt.Errorf("parsing RSS 1.0/RDF feed: %w", err)
+	}
+
+	feed := Feed{
+		Title:       doc.Channel.Title,
+		Link:        doc.Channel.Link,
+		Description: doc.Channel.Description,
+	}
+
+	for _, it := range doc.Items {
+		feed.Items = append(feed.Items, Item{
+			Title:       it.Title,
+			Link:        it.Link,
+			Description: it.Description,
+			Author:      it.Creator,
+			GUID:        firstNonEmpty(it.About, it.Link),
+			PubDate:     parseFeedDate(it.Date),
+		})
+	}
+
+	return feed, nil
+}
+
+type atomLinkXML struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+}
+
+// atomDoc mirrors Atom 1.0's <feed><entry> shape.
+type atomDoc struct {
+	XMLName  xml.Name      `xml:"feed"`
+	Title    string        `xml:"title"`
+	Subtitle string        `xml:"subtitle"`
+	Links    []atomLinkXML `xml:"link"`
+	Entries  []struct {
+		Title   string `xml:"title"`
+		Summary string `xml:"summary"`
+		Content string `xml:"content"`
+		Author  struct {
+			Name string `xml:"name"`
+		} `xml:"author"`
+		Categories []struct {
+			Term string `xml:"term,attr"`
+		} `xml:"category"`
+		ID        string        `xml:"id"`
+		Updated   string        `xml:"updated"`
+		Published string        `xml:"published"`
+		Links     []atomLinkXML `xml:"link"`
+	} `xml:"entry"`
+}
+
+func parseAtom(body []byte) (Feed, error) {
+	var doc atomDoc
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		return Feed{}, fmt.Errorf("parsing Atom feed: %w", err)
+	}
+
+	feed := Feed{
+		Title:       doc.Title,
+		Link:        atomLink(doc.Links),
+		Description: doc.Subtitle,
+	}
+
+	for _, e := range doc.Entries {
+		var categories []string
+		for _, c := range e.Categories {
+			if c.Term != "" {
+				categories = append(categories, c.Term)
+			}
+		}
+
+		link := atomLink(e.Links)
+
+		feed.Items = append(feed.Items, Item{
+			Title:       e.Title,
+			Link:        link,
+			Description: e.Summary,
+			Content:     e.Content,
+			Author:      e.Author.Name,
+			Categories:  categories,
+			GUID:        firstNonEmpty(e.ID, link),
+			PubDate:     parseFeedDate(firstNonEmpty(e.Published, e.Updated)),
+		})
+	}
+
+	return feed, nil
+}
+
+// atomLink picks the canonical link out of an Atom <link> set, preferring
+// rel="alternate" (the default when rel is omitted) over other relations
+// such as "self".
+func atomLink(links []atomLinkXML) string {
+	for _, l := range links {
+		if l.Rel == "" || l.Rel == "alternate" {
+			return l.Href
+		}
+	}
+	if len(links) > 0 {
+		return links[0].Href
+	}
+	return ""
+}
+
+var feedDateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC3339,
+	time.RFC822Z,
+	time.RFC822,
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+}
+
+// parseFeedDate tries feedDateLayouts in turn, since feeds in the wild
+// disagree on which RFC their pubDate/updated/published values follow. A
+// missing or unparseable date defaults to now rather than the zero time,
+// so the item isn't immediately eligible for cleanup's age-based purge.
+func parseFeedDate(raw string) time.Time {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return time.Now()
+	}
+
+	for _, layout := range feedDateLayouts {
+		if t, err := time.Parse(layout, raw); err == nil {
+			return t
+		}
+	}
+
+	log.Printf("> Unrecognized publication date format: %q", raw)
+	return time.Now()
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseFeedDate(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want time.Time
+	}{
+		{"RFC1123Z", "Mon, 02 Jan 2006 15:04:05 -0700", time.Date(2006, 1, 2, 15, 4, 5, 0, time.FixedZone("", -7*3600))},
+		{"RFC3339", "2006-01-02T15:04:05Z", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{"space separated", "2006-01-02 15:04:05", time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseFeedDate(tt.raw)
+			if !got.Equal(tt.want) {
+				t.Errorf("parseFeedDate(%q) = %v, want %v", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFeedDateFallsBackToNow(t *testing.T) {
+	for _, raw := range []string{"", "not a date", "   "} {
+		before := time.Now()
+		got := parseFeedDate(raw)
+		after := time.Now()
+
+		if got.Before(before) || got.After(after) {
+			t.Errorf("parseFeedDate(%q) = %v, want a time between %v and %v", raw, got, before, after)
+		}
+	}
+}
+
+func TestParseFeedRSS2(t *testing.T) {
+	body := []byte(`<?xml version="1.0"?>
+<rss version="2.0">
+  <channel>
+    <title>Example RSS</title>
+    <link>https://example.com</link>
+    <description>An example feed</description>
+    <item>
+      <title>First post</title>
+      <link>https://example.com/1</link>
+      <description>Body text</description>
+      <pubDate>Mon, 02 Jan 2006 15:04:05 -0700</pubDate>
+      <guid>https://example.com/1</guid>
+    </item>
+  </channel>
+</rss>`)
+
+	feed, err := parseFeed(body)
+	if err != nil {
+		t.Fatalf("parseFeed: %v", err)
+	}
+	if feed.Title != "Example RSS" || feed.Link != "https://example.com" {
+		t.Fatalf("unexpected feed: %+v", feed)
+	}
+	if len(feed.Items) != 1 || feed.Items[0].Title != "First post" {
+		t.Fatalf("unexpected items: %+v", feed.Items)
+	}
+}
+
+func TestParseFeedRDF(t *testing.T) {
+	body := []byte(`<?xml version="1.0"?>
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns:dc="http://purl.org/dc/elements/1.1/">
+  <channel>
+    <title>Example RDF</title>
+    <link>https://example.com</link>
+    <description>An example RDF feed</description>
+  </channel>
+  <item rdf:about="https://example.com/1">
+    <title>RDF item</title>
+    <link>https://example.com/1</link>
+    <description>Body text</description>
+    <dc:date>2006-01-02T15:04:05Z</dc:date>
+  </item>
+</rdf:RDF>`)
+
+	feed, err := parseFeed(body)
+	if err != nil {
+		t.Fatalf("parseFeed: %v", err)
+	}
+	if feed.Title != "Example RDF" {
+		t.Fatalf("unexpected feed: %+v", feed)
+	}
+	if len(feed.Items) != 1 || feed.Items[0].GUID != "https://example.com/1" {
+		t.Fatalf("unexpected items: %+v", feed.Items)
+	}
+}
+
+func TestParseFeedAtom(t *testing.T) {
+	body := []byte(`<?xml version="1.0"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Example Atom</title>
+  <subtitle>An example atom feed</subtitle>
+  <link rel="alternate" href="https://example.com"/>
+  <entry>
+    <title>Atom entry</title>
+    <content>Full content, no summary</content>
+    <id>https://example.com/1</id>
+    <published>2006-01-02T15:04:05Z</published>
+    <link rel="alternate" href="https://example.com/1"/>
+  </entry>
+</feed>`)
+
+	feed, err := parseFeed(body)
+	if err != nil {
+		t.Fatalf("parseFeed: %v", err)
+	}
+	if feed.Link != "https://example.com" {
+		t.Fatalf("unexpected feed link: %+v", feed)
+	}
+	if len(feed.Items) != 1 || feed.Items[0].Description != "" || feed.Items[0].Content != "Full content, no summary" {
+		t.Fatalf("unexpected items: %+v", feed.Items)
+	}
+}
+
+func TestParseFeedUnrecognizedRoot(t *testing.T) {
+	_, err := parseFeed([]byte(`<something/>`))
+	if err == nil {
+		t.Fatal("expected an error for an unrecognized root element")
+	}
+}
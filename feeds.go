@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// FeedRegistry tracks the set of feed URLs to scrape. It is seeded in
+// memory from feeds.txt (when present) on every startup, and Load merges
+// in the feeds persisted in the Feeds table. Additions/removals made
+// through the HTTP API are persisted to the Feeds table, so feeds.txt is
+// optional. Seeded feeds are not written to the Feeds table: a seeded feed
+// removed through the API is scraped again after a restart while it is
+// still listed in feeds.txt.
+type FeedRegistry struct {
+	db *sql.DB
+
+	mu    sync.RWMutex
+	feeds map[string]struct{}
+}
+
+func NewFeedRegistry(db *sql.DB, seed []string) *FeedRegistry {
+	feeds := make(map[string]struct{}, len(seed))
+	for _, f := range seed {
+		if f != "" {
+			feeds[f] = struct{}{}
+		}
+	}
+	return &FeedRegistry{db: db, feeds: feeds}
+}
+
+// ensureSchema creates the Feeds table if it doesn't already exist, so a
+// fresh DB doesn't leave Load/Add/Remove erroring against a missing
+// relation.
+func (r *FeedRegistry) ensureSchema(ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, `
+    CREATE TABLE IF NOT EXISTS Feeds (
+      feed_url TEXT PRIMARY KEY
+    )
+  `)
+	return err
+}
+
+// Load merges feed URLs persisted in the Feeds table into the in-memory
+// set, in addition to whatever was seeded from feeds.txt.
+func (r *FeedRegistry) Load(ctx context.Context) error {
+	rows, err := r.db.QueryContext(ctx, "SELECT feed_url FROM Feeds")
+	if err != nil {
+		return fmt.Errorf("loading persisted feeds: %w", err)
+	}
+	defer rows.Close()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for rows.Next() {
+		var feedUrl string
+		if err := rows.Scan(&feedUrl); err != nil {
+			return err
+		}
+		r.feeds[feedUrl] = struct{}{}
+	}
+	return rows.Err()
+}
+
+// List returns a snapshot of the currently registered feed URLs.
+func (r *FeedRegistry) List() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	out := make([]string, 0, len(r.feeds))
+	for f := range r.feeds {
+		out = append(out, f)
+	}
+	return out
+}
+
+// Add registers feedUrl for scraping and persists it so it survives a
+// restart.
+func (r *FeedRegistry) Add(ctx context.Context, feedUrl string) error {
+	feedUrl = strings.TrimSpace(feedUrl)
+	if feedUrl == "" {
+		return fmt.Errorf("feed url is empty")
+	}
+
+	_, err := r.db.ExecContext(
+		ctx,
+		"INSERT INTO Feeds (feed_url) VALUES ($1) ON CONFLICT (feed_url) DO NOTHING",
+		feedUrl,
+	)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.feeds[feedUrl] = struct{}{}
+	r.mu.Unlock()
+
+	return nil
+}
+
+// Remove stops feedUrl from being scraped and removes it from the DB.
+func (r *FeedRegistry) Remove(ctx context.Context, feedUrl string) error {
+	if _, err := r.db.ExecContext(ctx, "DELETE FROM Feeds WHERE feed_url = $1", feedUrl); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	delete(r.feeds, feedUrl)
+	r.mu.Unlock()
+
+	return nil
+}
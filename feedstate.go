@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// FeedState is the conditional-request bookkeeping kept for a single feed
+// across scrapingCycle runs.
+type FeedState struct {
+	FeedURL      string
+	ETag         string
+	LastModified string
+	LastFetched  time.Time
+	LastStatus   int
+	RetryAfter   time.Time
+}
+
+// feedStateStore persists FeedState in the feed_state table so a fetch
+// can send If-None-Match / If-Modified-Since for the next cycle and skip
+// feeds that are backing off after a 429/503.
+type feedStateStore struct {
+	db *sql.DB
+}
+
+func newFeedStateStore(db *sql.DB) *feedStateStore {
+	return &feedStateStore{db: db}
+}
+
+// ensureSchema creates the feed_state table if it doesn't already exist,
+// so a fresh DB doesn't leave every fetch erroring against a missing
+// relation.
+func (s *feedStateStore) ensureSchema(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `
+    CREATE TABLE IF NOT EXISTS feed_state (
+      feed_url      TEXT PRIMARY KEY,
+      etag          TEXT,
+      last_modified TEXT,
+      last_fetched  TIMESTAMPTZ,
+      last_status   INTEGER,
+      retry_after   TIMESTAMPTZ
+    )
+  `)
+	return err
+}
+
+func (s *feedStateStore) get(ctx context.Context, feedUrl string) (FeedState, error) {
+	state := FeedState{FeedURL: feedUrl}
+
+	var etag, lastModified sql.NullString
+	var lastFetched, retryAfter sql.NullTime
+	var lastStatus sql.NullInt32
+
+	err := s.db.QueryRowContext(
+		ctx,
+		"SELECT etag, last_modified, last_fetched, last_status, retry_after FROM feed_state WHERE feed_url = $1",
+		feedUrl,
+	).Scan(&etag, &lastModified, &lastFetched, &lastStatus, &retryAfter)
+
+	if err == sql.ErrNoRows {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+
+	state.ETag = etag.String
+	state.LastModified = lastModified.String
+	state.LastFetched = lastFetched.Time
+	state.LastStatus = int(lastStatus.Int32)
+	state.RetryAfter = retryAfter.Time
+	return state, nil
+}
+
+func (s *feedStateStore) save(ctx context.Context, state FeedState) error {
+	_, err := s.db.ExecContext(
+		ctx,
+		`INSERT INTO feed_state (feed_url, etag, last_modified, last_fetched, last_status, retry_after)
+      VALUES ($1, $2, $3, $4, $5, $6)
+      ON CONFLICT (feed_url) DO UPDATE SET
+        etag = EXCLUDED.etag,
+        last_modified = EXCLUDED.last_modified,
+        last_fetched = EXCLUDED.last_fetched,
+        last_status = EXCLUDED.last_status,
+        retry_after = EXCLUDED.retry_after
+    `,
+		state.FeedURL,
+		state.ETag,
+		state.LastModified,
+		state.LastFetched,
+		state.LastStatus,
+		state.RetryAfter,
+	)
+	return err
+}
+
+// httpFeedClient fetches feeds with conditional GETs, so a feed that
+// hasn't changed since the last cycle costs a 304 instead of a full
+// download and XML parse.
+type httpFeedClient struct {
+	states *feedStateStore
+}
+
+func newHTTPFeedClient(states *feedStateStore) *httpFeedClient {
+	return &httpFeedClient{states: states}
+}
+
+// fetch downloads feedUrl's body. ok is false with a nil error when
+// there's no work to do: the feed is unchanged (304) or it's still
+// backing off after a Retry-After from a previous 429/503.
+func (c *httpFeedClient) fetch(ctx context.Context, feedUrl string) (body []byte, ok bool, err error) {
+	state, err := c.states.get(ctx, feedUrl)
+	if err != nil {
+		return nil, false, fmt.Errorf("loading feed state for %s: %w", feedUrl, err)
+	}
+
+	if !state.RetryAfter.IsZero() && time.Now().Before(state.RetryAfter) {
+		return nil, false, nil
+	}
+
+	reqCtx, cancel := context.WithTimeout(ctx, feedTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, feedUrl, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("building request for %s: %w", feedUrl, err)
+	}
+	if state.ETag != "" {
+		req.Header.Set("If-None-Match", state.ETag)
+	}
+	if state.LastModified != "" {
+		req.Header.Set("If-Modified-Since", state.LastModified)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, false, fmt.Errorf("HTTP request error (%s): %w", feedUrl, err)
+	}
+	defer res.Body.Close()
+
+	next := FeedState{
+		FeedURL:      feedUrl,
+		ETag:         firstNonEmpty(res.Header.Get("ETag"), state.ETag),
+		LastModified: firstNonEmpty(res.Header.Get("Last-Modified"), state.LastModified),
+		LastFetched:  time.Now(),
+		LastStatus:   res.StatusCode,
+	}
+
+	switch {
+	case res.StatusCode == http.StatusNotModified:
+		if err := c.states.save(ctx, next); err != nil {
+			log.Println(err)
+		}
+		return nil, false, nil
+
+	case res.StatusCode == 429 || res.StatusCode == 503:
+		next.RetryAfter = parseRetryAfter(res.Header.Get("Retry-After"))
+		if err := c.states.save(ctx, next); err != nil {
+			log.Println(err)
+		}
+		return nil, false, fmt.Errorf("feed %s returned %d", feedUrl, res.StatusCode)
+
+	case res.StatusCode >= 400:
+		return nil, false, fmt.Errorf("feed %s returned %d", feedUrl, res.StatusCode)
+	}
+
+	body, err = io.ReadAll(res.Body)
+	if err != nil {
+		return nil, false, fmt.Errorf("response body reading error (%s): %w", feedUrl, err)
+	}
+
+	if err := c.states.save(ctx, next); err != nil {
+		log.Println(err)
+	}
+
+	return body, true, nil
+}
+
+// parseRetryAfter understands both the delay-seconds and HTTP-date forms
+// of the Retry-After header. It returns the zero time if header is empty
+// or unparsable.
+func parseRetryAfter(header string) time.Time {
+	if header == "" {
+		return time.Time{}
+	}
+	if secs, err := strconv.Atoi(header); err == nil {
+		return time.Now().Add(time.Duration(secs) * time.Second)
+	}
+	if t, err := http.ParseTime(header); err == nil {
+		return t
+	}
+	return time.Time{}
+}
@@ -1,18 +1,16 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"database/sql"
 	"encoding/base64"
-	"encoding/json"
-	"encoding/xml"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
 	"os"
+	"os/signal"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	elasticsearch "github.com/elastic/go-elasticsearch/v7"
@@ -23,38 +21,22 @@ import (
 const (
 	interval         = time.Hour
 	articleThreshold = time.Hour * 24 * 3
+	feedTimeout      = time.Second * 30
 )
 
-type Rss struct {
-	XMLName xml.Name `xml:"rss"`
-	Channel struct {
-		Title       string `xml:"title"`
-		Link        string `xml:"link"`
-		Description string `xml:"description"`
-		Items       []struct {
-			Title       string `xml:"title"`
-			Link        string `xml:"link"`
-			Description string `xml:"description"`
-			PubDate     string `xml:"pubDate"`
-		} `xml:"item"`
-	} `xml:"channel"`
-}
-
 type ESPayload struct {
 	Title       string `json:"title"`
 	Description string `json:"description"`
 }
 
-type ESUpdatePayload struct {
-	Doc struct {
-		Title       string `json:"title"`
-		Description string `json:"description"`
-	} `json:"doc"`
-}
-
-type ArticleIdAndPubDate struct {
-	id      string
-	pubDate sql.NullTime
+type ArticleESPayload struct {
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Content     string    `json:"content,omitempty"`
+	Link        string    `json:"link"`
+	Author      string    `json:"author,omitempty"`
+	Categories  []string  `json:"categories,omitempty"`
+	PubDate     time.Time `json:"pubDate"`
 }
 
 func initDB() *sql.DB {
@@ -93,68 +75,75 @@ func initElasticsearch() *elasticsearch.Client {
 	return esClient
 }
 
-func scrapeRss(feedUrl string) Rss {
-	res, err := http.Get(feedUrl)
-	if err != nil {
-		log.Fatal(fmt.Sprintf("HTTP request error (%s): %s", feedUrl, err))
-	}
-
-	defer res.Body.Close()
-
-	resBody, err := io.ReadAll(res.Body)
-	if err != nil {
-		log.Fatal("Response body reading error:", err)
+// scrapeRss fetches and parses feedUrl, sniffing whether it's RSS 2.0,
+// RSS 1.0/RDF or Atom 1.0. ok is false with a nil error when feedClient
+// determined there's nothing new to do (304 or backing off).
+func scrapeRss(ctx context.Context, feedClient *httpFeedClient, feedUrl string) (data Feed, ok bool, err error) {
+	body, ok, err := feedClient.fetch(ctx, feedUrl)
+	if err != nil || !ok {
+		return Feed{}, false, err
 	}
 
-	var parsedData Rss
-	err = xml.Unmarshal(resBody, &parsedData)
+	feed, err := parseFeed(body)
 	if err != nil {
-		log.Fatal(fmt.Sprintf("XML parsing error on %v ", feedUrl), err)
+		return Feed{}, false, fmt.Errorf("parsing feed %s: %w", feedUrl, err)
 	}
 
-	return parsedData
+	return feed, true, nil
 }
 
-func pushArticles(db *sql.DB, esClient *elasticsearch.Client, siteId string, data Rss) {
-	for _, article := range data.Channel.Items {
-		if article.Title == "" || article.Link == "" || article.Description == "" {
-			log.Println("> > Empty string encountered. Aborting.")
-			break
+func pushArticles(ctx context.Context, db *sql.DB, bi *BulkIndexer, siteId string, data Feed) error {
+	for _, item := range data.Items {
+		description := firstNonEmpty(item.Description, item.Content)
+
+		if item.Title == "" || item.Link == "" || description == "" {
+			log.Println("> > Empty string encountered. Skipping item.")
+			continue
 		}
 
-		articleId := base64.URLEncoding.EncodeToString([]byte(article.Link))
+		articleId := base64.URLEncoding.EncodeToString([]byte(firstNonEmpty(item.GUID, item.Link)))
 
-		title := strings.ReplaceAll(article.Title, "'", "`")
-		description := strings.ReplaceAll(article.Description, "'", "`")
+		title := strings.ReplaceAll(item.Title, "'", "`")
+		description = strings.ReplaceAll(description, "'", "`")
+		link := strings.ReplaceAll(item.Link, "'", "`")
 
 		err := pushArticleToDB(
+			ctx,
 			db,
 			articleId,
 			title,
-			strings.ReplaceAll(article.Link, "'", "`"),
+			link,
 			description,
-			strings.ReplaceAll(article.PubDate, "'", "`"),
+			item.PubDate,
 			siteId,
 		)
 
 		if err != nil {
-			log.Println(err)
-			return
+			return err
 		}
 
-		err = pushArticleToES(esClient, articleId, title, description)
+		payload := ArticleESPayload{
+			Title:       title,
+			Description: description,
+			Content:     item.Content,
+			Link:        link,
+			Author:      item.Author,
+			Categories:  item.Categories,
+			PubDate:     item.PubDate,
+		}
 
-		if err != nil {
-			log.Println(err)
-			return
+		if err := bi.Add(ctx, "articles", articleId, payload); err != nil {
+			return err
 		}
 	}
+	return nil
 }
 
-func pushArticleToDB(db *sql.DB, articleId, title, link, descrtiption, pubDate, siteId string) error {
-	_, err := db.Exec(
+func pushArticleToDB(ctx context.Context, db *sql.DB, articleId, title, link, descrtiption string, pubDate time.Time, siteId string) error {
+	_, err := db.ExecContext(
+		ctx,
 		`INSERT INTO Articles VALUES ($1, $2, $3, $4, $5, $6)
-      ON CONFLICT (id) DO UPDATE SET 
+      ON CONFLICT (id) DO UPDATE SET
         title = EXCLUDED.title,
         description = EXCLUDED.description
     `,
@@ -168,62 +157,23 @@ func pushArticleToDB(db *sql.DB, articleId, title, link, descrtiption, pubDate,
 	return err
 }
 
-func pushArticleToES(esClient *elasticsearch.Client, articleId, title, description string) error {
-	payload := ESUpdatePayload{
-		Doc: ESPayload{
-			Title:       title,
-			Description: description,
-		},
-	}
-	data, err := json.Marshal(payload)
-
-	if err != nil {
-		return err
-	}
-
-	res, err := esClient.Update("articles", articleId, bytes.NewReader(data))
-
-	defer res.Body.Close()
-
-	if res.IsError() {
-		if res.StatusCode == 404 {
-			createPayload := ESPayload{
-				Title:       payload.Doc.Title,
-				Description: payload.Doc.Description,
-			}
-			createData, err := json.Marshal(createPayload)
-			if err != nil {
-				return nil
-			}
-			res, err = esClient.Create("articles", articleId, bytes.NewReader(createData))
-			if res.IsError() {
-				errBody, err := io.ReadAll(res.Body)
-				if err == nil {
-					return fmt.Errorf("ES error: %s :: %s", res.Status(), string(errBody))
-				}
-			}
-		}
-	}
-
-	return err
-}
-
-func pushSite(db *sql.DB, esClient *elasticsearch.Client, feedUrl string, data Rss) (string, error) {
-	if data.Channel.Title == "" || data.Channel.Link == "" {
+func pushSite(ctx context.Context, db *sql.DB, bi *BulkIndexer, feedUrl string, data Feed) (string, error) {
+	if data.Title == "" || data.Link == "" {
 		return "", fmt.Errorf("> Empty string encountered. Aborting.")
 	}
 
-	title := strings.ReplaceAll(data.Channel.Title, "'", "`")
-	description := strings.ReplaceAll(data.Channel.Description, "'", "`")
+	title := strings.ReplaceAll(data.Title, "'", "`")
+	description := strings.ReplaceAll(data.Description, "'", "`")
 
 	siteId := base64.URLEncoding.EncodeToString([]byte(feedUrl))
 
 	err := pushSiteToDB(
+		ctx,
 		db,
 		siteId,
 		feedUrl,
 		title,
-		strings.ReplaceAll(data.Channel.Link, "'", "`"),
+		strings.ReplaceAll(data.Link, "'", "`"),
 		description,
 	)
 
@@ -231,20 +181,16 @@ func pushSite(db *sql.DB, esClient *elasticsearch.Client, feedUrl string, data R
 		return feedUrl, err
 	}
 
-	err = pushSiteToES(
-		esClient,
-		siteId,
-		title,
-		description,
-	)
+	err = bi.Add(ctx, "sites", siteId, ESPayload{Title: title, Description: description})
 
 	return siteId, err
 }
 
-func pushSiteToDB(db *sql.DB, siteId, feedUrl, title, link, description string) error {
+func pushSiteToDB(ctx context.Context, db *sql.DB, siteId, feedUrl, title, link, description string) error {
 
-	_, err := db.Exec(
-		`INSERT INTO Sites VALUES ($1, $2, $3, $4, $5) 
+	_, err := db.ExecContext(
+		ctx,
+		`INSERT INTO Sites VALUES ($1, $2, $3, $4, $5)
     ON CONFLICT (id) DO UPDATE SET
         title = EXCLUDED.title,
         description = EXCLUDED.description
@@ -259,144 +205,124 @@ func pushSiteToDB(db *sql.DB, siteId, feedUrl, title, link, description string)
 	return err
 }
 
-func pushSiteToES(esClient *elasticsearch.Client, siteId, title, description string) error {
-	payload := ESUpdatePayload{
-		Doc: ESPayload{
-			Title:       title,
-			Description: description,
-		},
-	}
-	data, err := json.Marshal(payload)
-
-	if err != nil {
-		return err
-	}
-
-	res, err := esClient.Update("sites", siteId, bytes.NewReader(data))
-
-	defer res.Body.Close()
-
-	if res.IsError() {
-		if res.StatusCode == 404 {
-			createPayload := ESPayload{
-				Title:       payload.Doc.Title,
-				Description: payload.Doc.Description,
-			}
-			createData, err := json.Marshal(createPayload)
-			if err != nil {
-				return nil
-			}
-			res, err = esClient.Create("sites", siteId, bytes.NewReader(createData))
-			if res.IsError() {
-				errBody, err := io.ReadAll(res.Body)
-				if err == nil {
-					return fmt.Errorf("ES error: %s :: %s", res.Status(), string(errBody))
-				}
-			}
-		}
-	}
-
-	return err
-}
-
-func cleanOutdated(db *sql.DB, esClient *elasticsearch.Client) {
-	log.Println("Cleanup...")
-
-	var articleInfo ArticleIdAndPubDate
-	rows, err := db.Query("SELECT id, pubdate from Articles")
-
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	defer rows.Close()
-
-	var scannedCount, deleteCount int
-	for rows.Next() {
-		if err := rows.Scan(&articleInfo.id, &articleInfo.pubDate); err != nil {
-			log.Fatal(err)
-		}
-		if time.Now().Sub(articleInfo.pubDate.Time) > articleThreshold {
-			_, err = db.Exec("DELETE FROM Articles WHERE id = $1", articleInfo.id)
-			if err != nil {
-				log.Fatal(err)
-			}
-
-			res, err := esClient.Delete("articles", articleInfo.id)
-
-			if err != nil {
-				log.Fatal(err)
-			}
-
-			if res.IsError() {
-				resBody, err := io.ReadAll(res.Body)
-				if err != nil {
-					log.Fatal(err)
-				}
-				log.Fatal("ES error:", resBody)
-			}
-
-			deleteCount += 1
-		}
-		scannedCount += 1
-	}
-
-	log.Printf("> Done. %d rows scanned. %d rows deleted", scannedCount, deleteCount)
-}
-
-func scrapingCycle(db *sql.DB, esClient *elasticsearch.Client, feeds []string) {
+func scrapingCycle(ctx context.Context, db *sql.DB, esClient *elasticsearch.Client, feedClient *httpFeedClient, feeds []string) {
 	log.Println("Scraping data...")
 
+	bi := NewBulkIndexer(esClient)
+
 	var wg sync.WaitGroup
 	wg.Add(len(feeds))
 
 	for _, url := range feeds {
 		go func(url string) {
-			res := scrapeRss(url)
-			siteId, err := pushSite(db, esClient, url, res)
+			defer wg.Done()
 
+			res, ok, err := scrapeRss(ctx, feedClient, url)
 			if err != nil {
 				log.Println(err)
-				wg.Done()
+				return
+			}
+			if !ok {
 				return
 			}
 
-			pushArticles(db, esClient, siteId, res)
-			wg.Done()
-			return
+			siteId, err := pushSite(ctx, db, bi, url, res)
+			if err != nil {
+				log.Println(err)
+				return
+			}
+
+			if err := pushArticles(ctx, db, bi, siteId, res); err != nil {
+				log.Println(err)
+			}
 		}(url)
 	}
 	wg.Wait()
+
+	if err := bi.Flush(ctx); err != nil {
+		log.Println(err)
+	}
 }
 
 func main() {
 	godotenv.Load(".env")
 
-	data, err := os.ReadFile("feeds.txt")
-	if err != nil {
-		log.Fatal(err)
-	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	feeds := strings.Split(string(data), "\n")
-	feeds = feeds[:len(feeds)-1]
+	var seedFeeds []string
+	if data, err := os.ReadFile("feeds.txt"); err == nil {
+		for _, f := range strings.Split(string(data), "\n") {
+			if f = strings.TrimSpace(f); f != "" {
+				seedFeeds = append(seedFeeds, f)
+			}
+		}
+	} else {
+		log.Println("feeds.txt not found, relying on feeds persisted in the DB:", err)
+	}
 
 	db := initDB()
 
 	defer func() {
 		if err := db.Close(); err != nil {
-			log.Fatal(err)
+			log.Println(err)
 		}
 	}()
 
 	esClient := initElasticsearch()
 
+	feeds := NewFeedRegistry(db, seedFeeds)
+	if err := feeds.ensureSchema(ctx); err != nil {
+		log.Fatal(err)
+	}
+	if err := feeds.Load(ctx); err != nil {
+		log.Println(err)
+	}
+
+	feedStates := newFeedStateStore(db)
+	if err := feedStates.ensureSchema(ctx); err != nil {
+		log.Fatal(err)
+	}
+	feedClient := newHTTPFeedClient(feedStates)
+
+	runCycle := func() {
+		scrapingCycle(ctx, db, esClient, feedClient, feeds.List())
+		if err := cleanOutdated(ctx, db, esClient); err != nil {
+			log.Println(err)
+		}
+	}
+
+	if jwtSecret := os.Getenv("JWT_SECRET"); jwtSecret != "" {
+		srv := startAPIServer(db, esClient, feeds, jwtSecret, runCycle)
+		go func() {
+			<-ctx.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := srv.Shutdown(shutdownCtx); err != nil {
+				log.Println(err)
+			}
+		}()
+	} else {
+		log.Println("JWT_SECRET not set, HTTP API disabled")
+	}
+
 	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 
 	for {
-		scrapingCycle(db, esClient, feeds)
-		cleanOutdated(db, esClient)
+		runCycle()
+
+		if ctx.Err() != nil {
+			log.Println("Shutting down...")
+			return
+		}
 
 		log.Println("Cycle over. Waiting for the next one...")
-		<-ticker.C
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			log.Println("Shutting down...")
+			return
+		}
 	}
 }